@@ -0,0 +1,276 @@
+package vfsgen
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	pathpkg "path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FromZip opens the zip archive at path and returns an http.FileSystem
+// over its contents, suitable for passing directly to Generate. It is not
+// an io/fs.FS, so it can't be passed to GenerateFromFS. Because zip supports random access, each entry's content is only read
+// and decompressed the first time it's opened. Per-entry modification
+// times are preserved from the archive.
+func FromZip(path string) (http.FileSystem, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := newMemTree()
+	for _, f := range zr.File {
+		fi := f.FileInfo()
+		if fi.IsDir() {
+			tree.addDir(f.Name, fi.ModTime())
+			continue
+		}
+		zf := f
+		tree.addFile(f.Name, fi.ModTime(), fi.Size(), func() ([]byte, error) {
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return ioutil.ReadAll(rc)
+		})
+	}
+	return tree, nil
+}
+
+// FromTar opens the tar archive at path (optionally gzip-compressed, if
+// gzipped is true) and returns an http.FileSystem over its contents,
+// suitable for passing directly to Generate. Unlike zip, tar doesn't
+// support random access, so FromTar reads the archive once up front to
+// build an in-memory index; each entry's content is read only once,
+// during that initial pass. Per-entry modification times are preserved
+// from the archive.
+func FromTar(path string, gzipped bool) (http.FileSystem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	tree := newMemTree()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			tree.addDir(hdr.Name, hdr.ModTime)
+		case tar.TypeReg:
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			tree.addFile(hdr.Name, hdr.ModTime, int64(len(data)), func() ([]byte, error) {
+				return data, nil
+			})
+		default:
+			// Symlinks, devices, etc. vfsgen only embeds regular files and directories.
+		}
+	}
+	return tree, nil
+}
+
+// memTree is a minimal in-memory http.FileSystem, built from a flat list
+// of archive entries. It synthesizes any parent directories an archive
+// doesn't list explicitly.
+type memTree struct {
+	nodes map[string]*memNode
+}
+
+func newMemTree() *memTree {
+	t := &memTree{nodes: make(map[string]*memNode)}
+	t.nodes["/"] = &memNode{name: "/", path: "/", isDir: true}
+	return t
+}
+
+func (t *memTree) ensureDir(path string, modTime time.Time) *memNode {
+	path = cleanArchivePath(path)
+	if n, ok := t.nodes[path]; ok {
+		return n
+	}
+	n := &memNode{name: pathpkg.Base(path), path: path, isDir: true, modTime: modTime}
+	t.nodes[path] = n
+	t.linkChild(n)
+	return n
+}
+
+func (t *memTree) addDir(name string, modTime time.Time) {
+	path := cleanArchivePath(name)
+	if path == "/" {
+		return
+	}
+	n := t.ensureDir(path, modTime)
+	n.modTime = modTime
+}
+
+func (t *memTree) addFile(name string, modTime time.Time, size int64, read func() ([]byte, error)) {
+	path := cleanArchivePath(name)
+	if path == "/" {
+		return
+	}
+	t.ensureDir(pathpkg.Dir(path), time.Time{})
+	n := &memNode{
+		name:    pathpkg.Base(path),
+		path:    path,
+		modTime: modTime,
+		size:    size,
+		data:    &lazyData{read: read},
+	}
+	t.nodes[path] = n
+	t.linkChild(n)
+}
+
+// linkChild attaches n to its parent's children, creating the parent as a
+// zero-modTime directory if an explicit entry for it hasn't been seen yet.
+func (t *memTree) linkChild(n *memNode) {
+	if n.path == "/" {
+		return
+	}
+	parent := t.ensureDir(pathpkg.Dir(n.path), time.Time{})
+	for _, c := range parent.children {
+		if c == n {
+			return
+		}
+	}
+	parent.children = append(parent.children, n)
+}
+
+func (t *memTree) Open(name string) (http.File, error) {
+	name = cleanArchivePath(name)
+	n, ok := t.nodes[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if n.isDir {
+		sort.Slice(n.children, func(i, j int) bool { return n.children[i].name < n.children[j].name })
+		return &memDir{node: n}, nil
+	}
+	data, err := n.data.get()
+	if err != nil {
+		return nil, err
+	}
+	return &memFile{node: n, Reader: bytes.NewReader(data)}, nil
+}
+
+// cleanArchivePath normalizes an archive entry name (which may be
+// relative, use "./" prefixes, or have a trailing slash for directories)
+// into an absolute, cleaned path as used by http.FileSystem.
+func cleanArchivePath(name string) string {
+	return pathpkg.Clean("/" + strings.TrimSuffix(name, "/"))
+}
+
+// lazyData memoizes a file's content, reading it at most once.
+type lazyData struct {
+	once sync.Once
+	read func() ([]byte, error)
+	data []byte
+	err  error
+}
+
+func (l *lazyData) get() ([]byte, error) {
+	l.once.Do(func() {
+		l.data, l.err = l.read()
+	})
+	return l.data, l.err
+}
+
+// memNode is either a file or a directory within a memTree.
+type memNode struct {
+	name     string
+	path     string
+	modTime  time.Time
+	size     int64
+	isDir    bool
+	data     *lazyData
+	children []*memNode
+}
+
+type memFileInfo struct{ n *memNode }
+
+func (fi memFileInfo) Name() string { return fi.n.name }
+func (fi memFileInfo) Size() int64  { return fi.n.size }
+func (fi memFileInfo) Mode() os.FileMode {
+	if fi.n.isDir {
+		return os.ModeDir | 0755
+	}
+	return 0444
+}
+func (fi memFileInfo) ModTime() time.Time { return fi.n.modTime }
+func (fi memFileInfo) IsDir() bool        { return fi.n.isDir }
+func (fi memFileInfo) Sys() interface{}   { return nil }
+
+// memFile is an opened regular file within a memTree.
+type memFile struct {
+	node *memNode
+	*bytes.Reader
+}
+
+func (f *memFile) Close() error               { return nil }
+func (f *memFile) Stat() (os.FileInfo, error) { return memFileInfo{f.node}, nil }
+func (f *memFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, fmt.Errorf("cannot Readdir from file %s", f.node.name)
+}
+
+// memDir is an opened directory within a memTree.
+type memDir struct {
+	node *memNode
+	pos  int
+}
+
+func (d *memDir) Close() error { return nil }
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, fmt.Errorf("cannot Read from directory %s", d.node.name)
+}
+func (d *memDir) Stat() (os.FileInfo, error) { return memFileInfo{d.node}, nil }
+func (d *memDir) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		d.pos = 0
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unsupported Seek in directory %s", d.node.name)
+}
+func (d *memDir) Readdir(count int) ([]os.FileInfo, error) {
+	children := d.node.children
+	if d.pos >= len(children) && count > 0 {
+		return nil, io.EOF
+	}
+	if count <= 0 || count > len(children)-d.pos {
+		count = len(children) - d.pos
+	}
+	infos := make([]os.FileInfo, count)
+	for i, c := range children[d.pos : d.pos+count] {
+		infos[i] = memFileInfo{c}
+	}
+	d.pos += count
+	return infos, nil
+}
@@ -0,0 +1,169 @@
+package vfsgen
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFromZip(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "assets.zip")
+
+	zf, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zf)
+	modTime := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	for _, e := range []struct {
+		name    string
+		content string
+	}{
+		{"hello.txt", "hello"},
+		{"sub/world.txt", "world"},
+	} {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		hdr.Modified = modTime
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := FromZip(zipPath)
+	if err != nil {
+		t.Fatalf("FromZip: %v", err)
+	}
+
+	f, err := fs.Open("/hello.txt")
+	if err != nil {
+		t.Fatalf("Open /hello.txt: %v", err)
+	}
+	content, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "hello"; got != want {
+		t.Errorf("/hello.txt content = %q, want %q", got, want)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if !fi.ModTime().Equal(modTime) {
+		t.Errorf("/hello.txt ModTime = %v, want %v", fi.ModTime(), modTime)
+	}
+
+	// "sub" is a directory synthesized from "sub/world.txt"'s path, since
+	// the zip archive never lists it explicitly.
+	dirFile, err := fs.Open("/sub")
+	if err != nil {
+		t.Fatalf("Open /sub: %v", err)
+	}
+	defer dirFile.Close()
+	dirInfo, err := dirFile.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dirInfo.IsDir() {
+		t.Error("/sub should be a synthesized directory")
+	}
+	entries, err := dirFile.Readdir(-1)
+	if err != nil {
+		t.Fatalf("Readdir /sub: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "world.txt" {
+		t.Errorf("/sub entries = %v, want [world.txt]", entries)
+	}
+}
+
+func TestFromTar(t *testing.T) {
+	dir := t.TempDir()
+	tarPath := filepath.Join(dir, "assets.tar.gz")
+
+	f, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	modTime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	content := "package contents"
+	hdr := &tar.Header{
+		Name:    "pkg/data.txt",
+		Size:    int64(len(content)),
+		Mode:    0644,
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := FromTar(tarPath, true)
+	if err != nil {
+		t.Fatalf("FromTar: %v", err)
+	}
+
+	of, err := fs.Open("/pkg/data.txt")
+	if err != nil {
+		t.Fatalf("Open /pkg/data.txt: %v", err)
+	}
+	defer of.Close()
+	got, err := ioutil.ReadAll(of)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("content = %q, want %q", got, content)
+	}
+	fi, err := of.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(modTime) {
+		t.Errorf("ModTime = %v, want %v", fi.ModTime(), modTime)
+	}
+
+	// "pkg" is a directory synthesized from "pkg/data.txt"'s path.
+	dirFile, err := fs.Open("/pkg")
+	if err != nil {
+		t.Fatalf("Open /pkg: %v", err)
+	}
+	defer dirFile.Close()
+	dirInfo, err := dirFile.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dirInfo.IsDir() {
+		t.Error("/pkg should be a synthesized directory")
+	}
+}
@@ -0,0 +1,42 @@
+package vfsgen
+
+import (
+	"io"
+)
+
+// commentWriter writes a Go line comment (using "//" prefixes) to the
+// underlying writer.
+type commentWriter struct {
+	W               io.Writer
+	wroteSlashSlash bool
+}
+
+func (cw *commentWriter) Write(p []byte) (int, error) {
+	var n int
+	for _, b := range p {
+		if !cw.wroteSlashSlash {
+			if _, err := io.WriteString(cw.W, "// "); err != nil {
+				return n, err
+			}
+			cw.wroteSlashSlash = true
+		}
+		if _, err := cw.W.Write([]byte{b}); err != nil {
+			return n, err
+		}
+		n++
+		if b == '\n' {
+			cw.wroteSlashSlash = false
+		}
+	}
+	return n, nil
+}
+
+// Close finishes the comment, writing a trailing "//" if the current line
+// doesn't have one yet (nothing was written, or the text ended with a newline).
+func (cw *commentWriter) Close() error {
+	if !cw.wroteSlashSlash {
+		_, err := io.WriteString(cw.W, "//")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package vfsgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// manyFilesTree returns a memTree of n files, each with distinct content,
+// so computeFiles has enough work to actually exercise opt.Concurrency's
+// worker pool rather than trivially falling back to a single worker.
+func manyFilesTree(n int) *memTree {
+	tree := newMemTree()
+	for i := 0; i < n; i++ {
+		content := []byte(fmt.Sprintf("file number %d\n", i))
+		path := fmt.Sprintf("/file%03d.txt", i)
+		tree.addFile(path, fixedModTime, int64(len(content)), func() ([]byte, error) { return content, nil })
+	}
+	return tree
+}
+
+// TestGenerateConcurrencyDeterministic checks that Generate's output (file
+// order, content) doesn't depend on how many workers opt.Concurrency gives
+// computeFiles to race across: each job's result is delivered on its own
+// channel and consumed in walk order by findAndWriteFiles, so whichever
+// worker happens to finish a job first can never leak into write order.
+func TestGenerateConcurrencyDeterministic(t *testing.T) {
+	tree := manyFilesTree(50)
+
+	serial := generateSource(t, tree, Options{VariableName: "assets", Concurrency: 1})
+	parallel := generateSource(t, tree, Options{VariableName: "assets", Concurrency: 8})
+
+	if serial != parallel {
+		t.Error("Generate output differs between Concurrency: 1 and Concurrency: 8, want identical")
+	}
+}
+
+// TestGenerateConcurrencyCorrectness checks that every file generated under
+// a concurrent worker pool still round-trips to its own (not some other
+// worker's) content.
+func TestGenerateConcurrencyCorrectness(t *testing.T) {
+	const n = 50
+	tree := manyFilesTree(n)
+
+	src := generateSource(t, tree, Options{VariableName: "assets", Concurrency: 8})
+
+	mainSrc := `package main
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+func main() {
+	for i := 0; i < ` + fmt.Sprint(n) + `; i++ {
+		path := fmt.Sprintf("/file%03d.txt", i)
+		f, err := assets.Open(path)
+		if err != nil {
+			fmt.Println("Open error:", path, err)
+			continue
+		}
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+		want := fmt.Sprintf("file number %d\n", i)
+		if string(b) != want || err != nil {
+			fmt.Println("mismatch:", path, string(b), err)
+		}
+	}
+	fmt.Println("done")
+}
+`
+	got := buildAndRun(t, src, mainSrc)
+	if got != "done\n" {
+		t.Errorf("output mismatch, want clean \"done\" with no prior lines:\n%s", got)
+	}
+}
+
+// failOnReopenFS wraps an http.FileSystem so that the first Open of path
+// (the walk's) succeeds and every later one (computeFile's) fails.
+type failOnReopenFS struct {
+	http.FileSystem
+	path  string
+	opens int32
+}
+
+func (fs *failOnReopenFS) Open(name string) (http.File, error) {
+	if name == fs.path && atomic.AddInt32(&fs.opens, 1) > 1 {
+		return nil, errors.New("boom")
+	}
+	return fs.FileSystem.Open(name)
+}
+
+// TestGenerateConcurrencyErrorDoesNotDeadlock checks that a failing job
+// (here, one buried in the middle of a tree far larger than
+// opt.Concurrency) still makes Generate return promptly: workers that
+// raced ahead and are blocked handing off their own results must be
+// unblocked by computeFiles' cancel, not left waiting forever for a
+// write loop that already bailed out.
+func TestGenerateConcurrencyErrorDoesNotDeadlock(t *testing.T) {
+	const n = 50
+	// /file025.txt opens fine during the walk, so its failure only surfaces
+	// when a worker reopens it in computeFile.
+	input := &failOnReopenFS{FileSystem: manyFilesTree(n), path: "/file025.txt"}
+
+	done := make(chan error, 1)
+	go func() {
+		var buf bytes.Buffer
+		opt := Options{Output: &buf, PackageName: "main", VariableName: "assets", Concurrency: 4}
+		done <- Generate(input, opt)
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Generate: want error, got nil")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Generate did not return: likely deadlocked on a blocked worker")
+	}
+}
@@ -0,0 +1,108 @@
+package vfsgen
+
+import (
+	"strings"
+	"testing"
+)
+
+// blobCount returns the number of package-level blob variables src defines,
+// one per unique content digest registered via Options.Dedupe (see
+// toc.blobFor).
+func blobCount(src string) int {
+	return strings.Count(src, "var vfsgenassets۰blob")
+}
+
+func TestGenerateDedupeFile(t *testing.T) {
+	shared := []byte("hello dedupe world")
+	tree := newMemTree()
+	tree.addFile("/a.txt", fixedModTime, int64(len(shared)), func() ([]byte, error) { return shared, nil })
+	tree.addFile("/b.txt", fixedModTime, int64(len(shared)), func() ([]byte, error) { return shared, nil })
+	tree.addFile("/c.txt", fixedModTime, 23, func() ([]byte, error) { return []byte("different content value"), nil })
+
+	src := generateSource(t, tree, Options{VariableName: "assets", Dedupe: DedupeFile})
+
+	// /a.txt and /b.txt share byte-identical content, so they should
+	// collapse to a single blob; /c.txt's distinct content is a second.
+	if got, want := blobCount(src), 2; got != want {
+		t.Errorf("blob count = %d, want %d (2 identical files should dedupe to 1 blob, plus 1 for the distinct file)", got, want)
+	}
+
+	const mainSrc = `package main
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+func main() {
+	for _, name := range []string{"/a.txt", "/b.txt", "/c.txt"} {
+		f, err := assets.Open(name)
+		if err != nil {
+			fmt.Println("Open error:", err)
+			return
+		}
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+		fmt.Println(string(b), err)
+	}
+}
+`
+	got := buildAndRun(t, src, mainSrc)
+	want := "hello dedupe world <nil>\n" +
+		"hello dedupe world <nil>\n" +
+		"different content value <nil>\n"
+	if got != want {
+		t.Errorf("output mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateDedupeChunkRoundTrip(t *testing.T) {
+	// With ChunkSize 4, "big.bin" splits into chunks "abcd", "abcd",
+	// "abcd", "WXYZ": the first three are byte-identical, so they should
+	// dedupe to a single blob, leaving 2 unique blobs overall.
+	content := []byte("abcdabcdabcdWXYZ")
+	tree := newMemTree()
+	tree.addFile("/big.bin", fixedModTime, int64(len(content)), func() ([]byte, error) { return content, nil })
+
+	opt := Options{VariableName: "assets", Dedupe: DedupeChunk, ChunkSize: 4}
+	src := generateSource(t, tree, opt)
+
+	if got, want := blobCount(src), 2; got != want {
+		t.Errorf("blob count = %d, want %d (3 identical chunks should dedupe to 1 blob, plus 1 for the distinct chunk)", got, want)
+	}
+
+	const mainSrc = `package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+func main() {
+	f, err := assets.Open("/big.bin")
+	if err != nil {
+		fmt.Println("Open error:", err)
+		return
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	fmt.Println(string(b), err)
+
+	seeker := f.(io.Seeker)
+	pos, err := seeker.Seek(6, io.SeekStart)
+	fmt.Println(pos, err)
+
+	rest, err := ioutil.ReadAll(f)
+	fmt.Println(string(rest), err)
+}
+`
+	got := buildAndRun(t, src, mainSrc)
+	want := "abcdabcdabcdWXYZ <nil>\n" +
+		"6 <nil>\n" +
+		"cdabcdWXYZ <nil>\n"
+	if got != want {
+		t.Errorf("output mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package vfsgen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateLeavesExistingFileOnError(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "assets_vfsdata.go")
+	if err := os.WriteFile(filename, []byte("// previous good generation\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tree := newMemTree()
+	// vfsutil.WalkFiles fails once it hits a file it can't read; addFile's
+	// read func returning an error is the simplest way to trigger that
+	// from a plain memTree, without a separate failingFS type.
+	tree.addFile("/bad.txt", fixedModTime, 3, func() ([]byte, error) { return nil, errors.New("boom") })
+
+	err := Generate(tree, Options{VariableName: "assets", Filename: filename})
+	if err == nil {
+		t.Fatal("Generate: want error, got nil")
+	}
+
+	got, readErr := os.ReadFile(filename)
+	if readErr != nil {
+		t.Fatalf("reading %s after failed Generate: %v", filename, readErr)
+	}
+	if string(got) != "// previous good generation\n" {
+		t.Errorf("existing %s was overwritten by a failed Generate, got:\n%s", filename, got)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "assets_vfsdata.go" {
+			t.Errorf("leftover file after failed Generate: %s", e.Name())
+		}
+	}
+}
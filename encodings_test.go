@@ -0,0 +1,112 @@
+package vfsgen
+
+import "testing"
+
+// compressible is long and repetitive enough to beat MinCompressRatio under
+// gzip, brotli and zstd alike.
+var compressible = []byte(func() string {
+	s := ""
+	for i := 0; i < 200; i++ {
+		s += "the quick brown fox jumps over the lazy dog. "
+	}
+	return s
+}())
+
+func TestGenerateBrotliAndZstd(t *testing.T) {
+	tree := newMemTree()
+	tree.addFile("/big.txt", fixedModTime, int64(len(compressible)), func() ([]byte, error) { return compressible, nil })
+
+	src := generateSource(t, tree, Options{
+		VariableName: "assets",
+		Encodings:    []Encoding{Brotli, Zstd},
+	})
+
+	mainSrc := `package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+type encodedByter interface {
+	EncodedBytes(encoding string) ([]byte, bool)
+}
+
+const want = ` + "`" + string(compressible) + "`" + `
+
+func main() {
+	f, err := assets.Open("/big.txt")
+	if err != nil {
+		fmt.Println("Open error:", err)
+		return
+	}
+	eb, ok := f.(encodedByter)
+	if !ok {
+		fmt.Println("not an encodedByter")
+		return
+	}
+
+	for _, enc := range []string{"gzip", "br", "zstd", "nonexistent"} {
+		data, ok := eb.EncodedBytes(enc)
+		fmt.Println(enc, ok, len(data) > 0 && len(data) < len(want))
+	}
+
+	gzipped, _ := eb.EncodedBytes("gzip")
+	gr, err := gzip.NewReader(bytes.NewReader(gzipped))
+	if err != nil {
+		fmt.Println("gzip.NewReader error:", err)
+		return
+	}
+	decoded, err := io.ReadAll(gr)
+	fmt.Println(string(decoded) == want, err)
+}
+`
+	got := buildAndRun(t, src, mainSrc)
+	want := "gzip true true\n" +
+		"br true true\n" +
+		"zstd true true\n" +
+		"nonexistent false false\n" +
+		"true <nil>\n"
+	if got != want {
+		t.Errorf("output mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateEncodingsSkippedForIncompressible(t *testing.T) {
+	// Random-looking, short content that won't beat MinCompressRatio, so no
+	// encoding (not even gzip) should be embedded for it.
+	incompressible := []byte{0x00, 0xff, 0x13, 0x37, 0xde, 0xad, 0xbe, 0xef}
+	tree := newMemTree()
+	tree.addFile("/tiny.bin", fixedModTime, int64(len(incompressible)), func() ([]byte, error) { return incompressible, nil })
+
+	src := generateSource(t, tree, Options{
+		VariableName: "assets",
+		Encodings:    []Encoding{Brotli, Zstd},
+	})
+
+	const mainSrc = `package main
+
+import "fmt"
+
+type encodedByter interface {
+	EncodedBytes(encoding string) ([]byte, bool)
+}
+
+func main() {
+	f, err := assets.Open("/tiny.bin")
+	if err != nil {
+		fmt.Println("Open error:", err)
+		return
+	}
+	_, ok := f.(encodedByter)
+	fmt.Println("is encodedByter:", ok)
+}
+`
+	got := buildAndRun(t, src, mainSrc)
+	want := "is encodedByter: false\n"
+	if got != want {
+		t.Errorf("output mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
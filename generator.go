@@ -1,21 +1,28 @@
 package vfsgen
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
-	"errors"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	pathpkg "path"
+	"path/filepath"
 	"sort"
 	"strconv"
+	"sync"
 	"text/template"
 	"time"
 
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 	"github.com/shurcooL/httpfs/vfsutil"
 )
 
@@ -46,40 +53,97 @@ func regenTemplateWithOptions(opt Options) {
 }
 
 // Generate Go code that statically implements input filesystem,
-// write the output to a file specified in opt.
+// streaming the output to opt.Output if set, or else to a newly created
+// file at opt.Filename.
 func Generate(input http.FileSystem, opt Options) error {
 	opt.fillMissing()
 	// regenerate template string with options filled in, subsequent calls will use this template
 	regenTemplateWithOptions(opt)
 
-	// Use an in-memory buffer to generate the entire output.
-	buf := new(bytes.Buffer)
+	dst, finish, err := destination(opt)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(dst)
+	err = generate(w, input, opt)
+	if err == nil {
+		err = w.Flush()
+	}
+	return finish(err)
+}
+
+// destination resolves where Generate writes its output: opt.Output, if
+// set, or else a temporary file created alongside opt.Filename. The
+// returned finish func must be called exactly once with the error (if any)
+// from writing to dst: given a nil error, it renames the temporary file
+// into place at opt.Filename; given a non-nil error, it discards the
+// temporary file and returns the error unchanged, leaving any existing
+// opt.Filename untouched rather than overwriting it with a truncated
+// generate. finish is a no-op (beyond returning err) for opt.Output, which
+// the caller owns.
+func destination(opt Options) (dst io.Writer, finish func(error) error, err error) {
+	if opt.Output != nil {
+		return opt.Output, func(err error) error { return err }, nil
+	}
+
+	fmt.Println("writing", opt.Filename)
+	tmp, err := os.CreateTemp(filepath.Dir(opt.Filename), filepath.Base(opt.Filename)+".tmp-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	finish = func(genErr error) error {
+		if genErr != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return genErr
+		}
+		if err := tmp.Close(); err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+		if err := os.Rename(tmp.Name(), opt.Filename); err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+		return nil
+	}
+	return tmp, finish, nil
+}
 
-	err := t.ExecuteTemplate(buf, "Header", opt)
+// generate writes the full generated file to w: Header, every DirInfo/
+// FileInfo/CompressedFileInfo/ChunkedFileInfo entry, the deduplicated
+// content Blobs, and the Trailer.
+func generate(w io.Writer, input http.FileSystem, opt Options) error {
+	err := t.ExecuteTemplate(w, "Header", opt)
 	if err != nil {
 		return err
 	}
 
-	var toc toc
-	err = findAndWriteFiles(buf, input, &toc)
+	toc := toc{blobIndex: make(map[string]string)}
+	err = findAndWriteFiles(w, input, &toc, opt)
 	if err != nil {
 		return err
 	}
 
-	err = t.ExecuteTemplate(buf, "DirEntries", toc.dirs)
+	err = t.ExecuteTemplate(w, "DirEntries", toc.dirs)
 	if err != nil {
 		return err
 	}
 
-	err = t.ExecuteTemplate(buf, "Trailer", toc)
+	err = writeBlobs(w, toc.Blobs)
 	if err != nil {
 		return err
 	}
 
-	// Write output file (all at once).
-	fmt.Println("writing", opt.Filename)
-	err = ioutil.WriteFile(opt.Filename, buf.Bytes(), 0644)
-	return err
+	return t.ExecuteTemplate(w, "Trailer", toc)
+}
+
+// GenerateFromFS is like Generate, but it takes an io/fs.FS input (such as an
+// embed.FS or os.DirFS) rather than an http.FileSystem, adapting it
+// internally for the walk via http.FS.
+func GenerateFromFS(input fs.FS, opt Options) error {
+	return Generate(http.FS(input), opt)
 }
 
 type toc struct {
@@ -87,6 +151,33 @@ type toc struct {
 
 	HasCompressedFile bool // There's at least one compressedFile.
 	HasFile           bool // There's at least one uncompressed file.
+	HasChunkedFile    bool // There's at least one chunked file (see Options.Dedupe).
+
+	Blobs     []*blobInfo       // Deduplicated content blobs, in first-seen order.
+	blobIndex map[string]string // digest (hex) -> blob variable name, used by blobFor.
+}
+
+// blobInfo is a deduplicated content blob. It's emitted once as a
+// package-level []byte variable and referenced by every file or chunk
+// whose content is byte-identical to it.
+type blobInfo struct {
+	VarName string
+	Content []byte
+}
+
+// blobFor returns the variable name of the blob holding content, registering
+// a new blob the first time this exact byte sequence is seen during this
+// Generate call.
+func (toc *toc) blobFor(content []byte) string {
+	digest := sha256.Sum256(content)
+	key := hex.EncodeToString(digest[:])
+	if name, ok := toc.blobIndex[key]; ok {
+		return name
+	}
+	name := fmt.Sprintf("blob%d", len(toc.Blobs))
+	toc.blobIndex[key] = name
+	toc.Blobs = append(toc.Blobs, &blobInfo{VarName: name, Content: content})
+	return name
 }
 
 // fileInfo is a definition of a file.
@@ -105,77 +196,160 @@ type dirInfo struct {
 	Entries []string
 }
 
-// findAndWriteFiles recursively finds all the file paths in the given directory tree.
-// They are added to the given map as keys. Values will be safe function names
-// for each file, which will be used when generating the output code.
-func findAndWriteFiles(buf *bytes.Buffer, fs http.FileSystem, toc *toc) error {
+// walkItem is either a directory (isDir true, entries set) or a file
+// (job set) encountered while walking the input filesystem, kept in walk
+// order so output can be written in that same order, each file as soon as
+// its own job has been computed.
+type walkItem struct {
+	isDir   bool
+	path    string
+	fi      os.FileInfo
+	entries []string // only for dirs
+	job     *fileJob // only for files
+}
+
+// fileJob is a file discovered while walking the input filesystem,
+// identifying it for computeFiles without holding its content: that's
+// read lazily, one in-flight job's worth at a time, by computeFiles' workers.
+type fileJob struct {
+	path string
+	fi   os.FileInfo
+}
+
+// fileResult is a fileJob's outcome, sent back on its dedicated channel in
+// computeFiles.
+type fileResult struct {
+	file *encodedFile
+	err  error
+}
+
+// findAndWriteFiles walks the input filesystem and writes DirInfo/FileInfo/
+// CompressedFileInfo/ChunkedFileInfo entries to w in the order files and
+// directories were encountered. File content is computed (compressed,
+// chunked, or plain) across up to opt.Concurrency workers running ahead of
+// the write loop, but writeEncodedFile only runs once a file's turn comes
+// up in walk order, so memory stays bounded to roughly opt.Concurrency
+// files' worth of content and encoded output rather than the whole tree's.
+func findAndWriteFiles(w io.Writer, fs http.FileSystem, toc *toc, opt Options) error {
+	var items []walkItem
+	var jobs []*fileJob
+
 	walkFn := func(path string, fi os.FileInfo, r io.ReadSeeker, err error) error {
 		if err != nil {
 			// Consider all errors reading the input filesystem as fatal.
 			return err
 		}
 
-		switch fi.IsDir() {
-		case false:
-			file := &fileInfo{
-				Path:             path,
-				Name:             pathpkg.Base(path),
-				ModTime:          fi.ModTime().UTC(),
-				UncompressedSize: fi.Size(),
-			}
-
-			marker := buf.Len()
-
-			// Write CompressedFileInfo.
-			err = writeCompressedFileInfo(buf, file, r)
-			switch err {
-			default:
-				return err
-			case nil:
-				toc.HasCompressedFile = true
-			// If compressed file is not smaller than original, revert and write original file.
-			case errCompressedNotSmaller:
-				_, err = r.Seek(0, io.SeekStart)
-				if err != nil {
-					return err
-				}
-
-				buf.Truncate(marker)
-
-				// Write FileInfo.
-				err = writeFileInfo(buf, file, r)
-				if err != nil {
-					return err
-				}
-				toc.HasFile = true
-			}
-		case true:
+		if fi.IsDir() {
 			entries, err := readDirPaths(fs, path)
 			if err != nil {
 				return err
 			}
+			items = append(items, walkItem{isDir: true, path: path, fi: fi, entries: entries})
+			return nil
+		}
+
+		// Content isn't read here: vfsutil.WalkFiles closes r as soon as
+		// walkFn returns, and reading every file up front is exactly the
+		// memory spike computeFiles' pipelining avoids. A worker reopens
+		// the file (via fs.Open) once it's ready to compute this job.
+		job := &fileJob{path: path, fi: fi}
+		items = append(items, walkItem{path: path, fi: fi, job: job})
+		jobs = append(jobs, job)
+		return nil
+	}
+
+	if err := vfsutil.WalkFiles(fs, "/", walkFn); err != nil {
+		return err
+	}
+
+	results, cancel := computeFiles(fs, jobs, opt)
+	defer cancel()
 
+	fileIdx := 0
+	for _, item := range items {
+		if item.isDir {
 			dir := &dirInfo{
-				Path:    path,
-				Name:    pathpkg.Base(path),
-				ModTime: fi.ModTime().UTC(),
-				Entries: entries,
+				Path:    item.path,
+				Name:    pathpkg.Base(item.path),
+				ModTime: item.fi.ModTime().UTC(),
+				Entries: item.entries,
 			}
-
 			toc.dirs = append(toc.dirs, dir)
 
-			// Write DirInfo.
-			err = t.ExecuteTemplate(buf, "DirInfo", dir)
-			if err != nil {
+			if err := t.ExecuteTemplate(w, "DirInfo", dir); err != nil {
 				return err
 			}
+			continue
 		}
 
-		return nil
+		res := <-results[fileIdx]
+		fileIdx++
+		if res.err != nil {
+			return res.err
+		}
+		if err := writeEncodedFile(w, res.file, toc, opt); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	err := vfsutil.WalkFiles(fs, "/", walkFn)
-	return err
+// computeFiles starts up to opt.Concurrency workers computing jobs'
+// encoded representations, and returns one result channel per job, in the
+// same order as jobs. Each channel is unbuffered: a worker that finishes a
+// job ahead of the caller's turn to consume it blocks holding that job's
+// memory (content plus encoded output) rather than moving on to the next
+// job, so at most opt.Concurrency jobs' worth of memory is ever live at
+// once, regardless of how large the whole input tree is. The returned
+// cancel func must be called once the caller is done consuming results
+// (including on an early return due to error), so workers blocked sending
+// a result nobody will read can exit instead of leaking.
+func computeFiles(fs http.FileSystem, jobs []*fileJob, opt Options) (results []chan fileResult, cancel func()) {
+	results = make([]chan fileResult, len(jobs))
+	for i := range results {
+		results[i] = make(chan fileResult)
+	}
+
+	concurrency := opt.Concurrency
+	if concurrency > len(jobs) {
+		concurrency = len(jobs)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	indices := make(chan int, len(jobs))
+	for i := range jobs {
+		indices <- i
+	}
+	close(indices)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for n := 0; n < concurrency; n++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				file, err := computeFile(fs, jobs[i], opt)
+				select {
+				case results[i] <- fileResult{file: file, err: err}:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			close(stop)
+			wg.Wait()
+		})
+	}
+	return results, cancel
 }
 
 // readDirPaths reads the directory named by dirname and returns
@@ -193,45 +367,350 @@ func readDirPaths(fs http.FileSystem, dirname string) ([]string, error) {
 	return paths, nil
 }
 
-// writeCompressedFileInfo writes CompressedFileInfo.
-// It returns errCompressedNotSmaller if compressed file is not smaller than original.
-func writeCompressedFileInfo(w io.Writer, file *fileInfo, r io.Reader) error {
+// sniffSize is how much of a large file is gzipped up front to decide
+// whether compressing the rest is worthwhile, mirroring the approach
+// fasthttp's isFileCompressible takes.
+const sniffSize = 4096
+
+// encodedFile is a file's computed on-disk representation: either a
+// sequence of deduplicated, individually gzip-compressed chunks, a gzip-
+// (and maybe brotli/zstd-) compressed blob, or (if neither applies) its
+// plain content. Computing one doesn't touch toc (its only side effect is
+// opt.Verbose logging), so computeFiles can run it across a worker pool; writing it
+// (writeEncodedFile) is serial, since it mutates toc.
+type encodedFile struct {
+	file *fileInfo
+
+	chunked bool
+	chunks  [][]byte // each chunk gzip-compressed independently; see gzipChunks
+
+	compressed bool
+	gzipped    []byte
+	brotli     []byte // nil if brotli wasn't requested or didn't help
+	zstd       []byte // nil if zstd wasn't requested or didn't help
+
+	content []byte // used when neither chunked nor compressed
+}
+
+// computeFile reopens job's content from fs and computes its on-disk
+// representation. It does not touch toc, so it's safe to call concurrently
+// across jobs.
+func computeFile(fs http.FileSystem, job *fileJob, opt Options) (*encodedFile, error) {
+	f, err := fs.Open(job.path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	file := &fileInfo{
+		Path:             job.path,
+		Name:             pathpkg.Base(job.path),
+		ModTime:          job.fi.ModTime().UTC(),
+		UncompressedSize: job.fi.Size(),
+	}
+
+	if opt.chunked(file.UncompressedSize) {
+		chunks, err := gzipChunks(splitChunks(content, opt.ChunkSize))
+		if err != nil {
+			return nil, err
+		}
+		if opt.Verbose {
+			log.Printf("vfsgen: %s: split into %d gzip-compressed chunks of up to %d bytes", file.Path, len(chunks), opt.ChunkSize)
+		}
+		return &encodedFile{file: file, chunked: true, chunks: chunks}, nil
+	}
+
+	gzipped, ok, err := tryCompress(content, file, opt)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &encodedFile{file: file, content: content}, nil
+	}
+
+	ef := &encodedFile{file: file, compressed: true, gzipped: gzipped}
+	if opt.NeedsBrotli() {
+		ef.brotli, err = encodeIfSmaller(content, file, func(dst io.Writer) io.WriteCloser {
+			return brotli.NewWriter(dst)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opt.NeedsZstd() {
+		ef.zstd, err = encodeIfSmaller(content, file, func(dst io.Writer) io.WriteCloser {
+			zw, err := zstd.NewWriter(dst)
+			if err != nil {
+				// Only invalid options passed to NewWriter can cause this, which never happens here.
+				panic(err)
+			}
+			return zw
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ef, nil
+}
+
+// splitChunks splits content into chunkSize-sized pieces, the last of
+// which may be shorter.
+func splitChunks(content []byte, chunkSize int64) [][]byte {
+	var chunks [][]byte
+	for len(content) > 0 {
+		n := chunkSize
+		if int64(len(content)) < n {
+			n = int64(len(content))
+		}
+		chunks = append(chunks, content[:n])
+		content = content[n:]
+	}
+	return chunks
+}
+
+// gzipChunks gzip-compresses each of chunks independently, unlike
+// tryCompress, always: chunking only kicks in for large files (see
+// Options.ChunkSize), where skipping compression for a poorly-compressing
+// chunk saves little next to the cost of storing chunks inconsistently
+// (ChunkedFile would otherwise need a per-chunk compressed flag, and
+// EncodedBytes couldn't just concatenate chunks for gzip negotiation).
+// Compressing each chunk separately, rather than the whole file, means
+// chunk boundaries stay the unit of deduplication (see Options.Dedupe).
+func gzipChunks(chunks [][]byte) ([][]byte, error) {
+	gzipped := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		if _, err := gw.Write(chunk); err != nil {
+			return nil, err
+		}
+		if err := gw.Close(); err != nil {
+			return nil, err
+		}
+		gzipped[i] = buf.Bytes()
+	}
+	return gzipped, nil
+}
+
+// tryCompress gzips content and reports whether it's worth embedding:
+// false means compression was skipped outright because of
+// opt.SkipCompressExtensions or a poor sniffed ratio, or the gzipped
+// result didn't beat opt.MinCompressRatio; the caller should then embed
+// content uncompressed instead.
+func tryCompress(content []byte, file *fileInfo, opt Options) ([]byte, bool, error) {
+	if opt.SkipsCompression(file.Path) {
+		if opt.Verbose {
+			log.Printf("vfsgen: %s: skipping compression (excluded extension)", file.Path)
+		}
+		return nil, false, nil
+	}
+
+	if file.UncompressedSize > sniffSize {
+		sniff := content
+		if int64(len(sniff)) > sniffSize {
+			sniff = sniff[:sniffSize]
+		}
+		compressible, err := isCompressible(sniff, opt.MinCompressRatio)
+		if err != nil {
+			return nil, false, err
+		}
+		if !compressible {
+			if opt.Verbose {
+				log.Printf("vfsgen: %s: skipping compression (first %d bytes don't compress well)", file.Path, sniffSize)
+			}
+			return nil, false, nil
+		}
+	}
+
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write(content)
+	if err != nil {
+		return nil, false, err
+	}
+	err = gw.Close()
+	if err != nil {
+		return nil, false, err
+	}
+	if ratio := float64(gzipped.Len()) / float64(file.UncompressedSize); ratio > opt.MinCompressRatio {
+		if opt.Verbose {
+			log.Printf("vfsgen: %s: gzip %d -> %d bytes (%.0f%%), not below ratio %.2f", file.Path, file.UncompressedSize, gzipped.Len(), ratio*100, opt.MinCompressRatio)
+		}
+		return nil, false, nil
+	} else if opt.Verbose {
+		log.Printf("vfsgen: %s: gzip %d -> %d bytes (%.0f%%)", file.Path, file.UncompressedSize, gzipped.Len(), ratio*100)
+	}
+	return gzipped.Bytes(), true, nil
+}
+
+// encodeIfSmaller encodes content with newEncoder, returning nil if the
+// encoded form doesn't beat file's uncompressed size.
+func encodeIfSmaller(content []byte, file *fileInfo, newEncoder func(io.Writer) io.WriteCloser) ([]byte, error) {
+	var encoded bytes.Buffer
+	enc := newEncoder(&encoded)
+	_, err := enc.Write(content)
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Close()
+	if err != nil {
+		return nil, err
+	}
+	if int64(encoded.Len()) >= file.UncompressedSize {
+		return nil, nil
+	}
+	return encoded.Bytes(), nil
+}
+
+// writeEncodedFile writes ef's computed representation and records which
+// of toc's Has*File flags it corresponds to.
+func writeEncodedFile(w io.Writer, ef *encodedFile, toc *toc, opt Options) error {
+	switch {
+	case ef.chunked:
+		toc.HasChunkedFile = true
+		return writeChunkedFileInfo(w, ef.file, ef.chunks, toc, opt)
+	case ef.compressed:
+		toc.HasCompressedFile = true
+		return writeCompressedFileInfo(w, ef, toc, opt)
+	default:
+		toc.HasFile = true
+		return writeFileInfo(w, ef.file, ef.content, toc, opt)
+	}
+}
+
+// writeCompressedFileInfo writes a CompressedFileInfo entry for ef, an
+// already-compressed file. Additional encodings requested via
+// opt.Encodings (Brotli, Zstd) are embedded only if present in ef (that
+// is, if they beat the uncompressed size; see encodeIfSmaller).
+func writeCompressedFileInfo(w io.Writer, ef *encodedFile, toc *toc, opt Options) error {
+	file := ef.file
 	err := t.ExecuteTemplate(w, "CompressedFileInfo-Before", file)
 	if err != nil {
 		return err
 	}
-	sw := &stringWriter{Writer: w}
-	gw := gzip.NewWriter(sw)
-	_, err = io.Copy(gw, r)
+	err = writeContentField(w, "compressedContent", ef.gzipped, toc, opt)
 	if err != nil {
 		return err
 	}
-	err = gw.Close()
+
+	if opt.NeedsBrotli() && ef.brotli != nil {
+		err = writeContentField(w, "brotliContent", ef.brotli, toc, opt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opt.NeedsZstd() && ef.zstd != nil {
+		err = writeContentField(w, "zstdContent", ef.zstd, toc, opt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.ExecuteTemplate(w, "CompressedFileInfo-Close", file)
+}
+
+// writeContentField writes a single []byte struct field. When opt.Dedupe
+// is enabled, content is written as a reference to a shared package-level
+// blob variable rather than re-embedded, so byte-identical content (gzip
+// of the same bytes is itself deterministic, so this also dedupes across
+// files sharing raw content) only appears once in the generated file.
+func writeContentField(w io.Writer, field string, content []byte, toc *toc, opt Options) error {
+	if opt.NeedsDedupe() {
+		_, err := fmt.Fprintf(w, "\t\t\t%s: %s,\n", field, blobRef(toc, opt, content))
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\t\t\t%s: []byte(\"", field)
 	if err != nil {
 		return err
 	}
-	if sw.N >= file.UncompressedSize {
-		return errCompressedNotSmaller
+	sw := &stringWriter{Writer: w}
+	_, err = sw.Write(content)
+	if err != nil {
+		return err
 	}
-	err = t.ExecuteTemplate(w, "CompressedFileInfo-After", file)
+	_, err = fmt.Fprint(w, "\"),\n")
 	return err
 }
 
-var errCompressedNotSmaller = errors.New("compressed file is not smaller than original")
+// blobRef registers content in toc (reusing an existing blob if identical
+// content was already seen) and returns the generated package-level
+// variable name it's stored under.
+func blobRef(toc *toc, opt Options, content []byte) string {
+	return fmt.Sprintf("vfsgen%s۰%s", opt.VariableName, toc.blobFor(content))
+}
 
-// Write FileInfo.
-func writeFileInfo(w io.Writer, file *fileInfo, r io.Reader) error {
+// writeBlobs writes the content blobs collected by findAndWriteFiles (via
+// Options.Dedupe) as package-level []byte variables.
+func writeBlobs(w io.Writer, blobs []*blobInfo) error {
+	for _, b := range blobs {
+		err := t.ExecuteTemplate(w, "Blob-Before", b)
+		if err != nil {
+			return err
+		}
+		sw := &stringWriter{Writer: w}
+		_, err = sw.Write(b.Content)
+		if err != nil {
+			return err
+		}
+		err = t.ExecuteTemplate(w, "Blob-After", b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunkedFileInfo is a definition of a large file stored as a sequence of
+// deduplicated, fixed-size, individually gzip-compressed content chunks
+// (see Options.Dedupe and Options.ChunkSize).
+type chunkedFileInfo struct {
+	*fileInfo
+	ChunkVars []string
+}
+
+// writeChunkedFileInfo dedupes each of file's already-split, already-gzipped
+// chunks as a shared blob, and writes a ChunkedFileInfo entry referencing
+// them in order.
+func writeChunkedFileInfo(w io.Writer, file *fileInfo, chunks [][]byte, toc *toc, opt Options) error {
+	cf := &chunkedFileInfo{fileInfo: file}
+	for _, chunk := range chunks {
+		cf.ChunkVars = append(cf.ChunkVars, blobRef(toc, opt, chunk))
+	}
+	return t.ExecuteTemplate(w, "ChunkedFileInfo", cf)
+}
+
+// isCompressible gzips sniff (the first sniffSize bytes of a file, or all
+// of it if smaller) to estimate whether compressing the rest of the file
+// is likely to beat minRatio.
+func isCompressible(sniff []byte, minRatio float64) (bool, error) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	if _, err := gw.Write(sniff); err != nil {
+		return false, err
+	}
+	if err := gw.Close(); err != nil {
+		return false, err
+	}
+	return float64(gzipped.Len())/float64(len(sniff)) <= minRatio, nil
+}
+
+// writeFileInfo writes a FileInfo entry for file's uncompressed content.
+func writeFileInfo(w io.Writer, file *fileInfo, content []byte, toc *toc, opt Options) error {
 	err := t.ExecuteTemplate(w, "FileInfo-Before", file)
 	if err != nil {
 		return err
 	}
-	sw := &stringWriter{Writer: w}
-	_, err = io.Copy(sw, r)
+	err = writeContentField(w, "content", content, toc, opt)
 	if err != nil {
 		return err
 	}
-	err = t.ExecuteTemplate(w, "FileInfo-After", file)
-	return err
+	return t.ExecuteTemplate(w, "FileInfo-After", file)
 }
 
 var templateString = `{{define "Header"}}// Code generated by vfsgen; DO NOT EDIT.
@@ -246,14 +725,17 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
+<<<if .NeedsIOFS>>>	iofs "io/fs"
+<<<end>>>	"net/http"
 	"os"
 	pathpkg "path"
-	"time"
+<<<if .NeedsIOFS>>>	"sort"
+	"strings"
+<<<end>>>	"time"
 )
 
 {{comment .VariableComment}}
-var {{.VariableName}} = func() http.FileSystem {
+var {{.VariableName}} = func() <<<if .NeedsIOFS>>>iofs.FS<<<else>>>http.FileSystem<<<end>>> {
 	fs := vfsgen<<<.VariableName>>>۰FS{
 {{end}}
 
@@ -263,9 +745,11 @@ var {{.VariableName}} = func() http.FileSystem {
 			name:             {{quote .Name}},
 			modTime:          {{template "Time" .ModTime}},
 			uncompressedSize: {{.UncompressedSize}},
-{{/* This blank line separating compressedContent is neccessary to prevent potential gofmt issues. See issue #19. */}}
-			compressedContent: []byte("{{end}}{{define "CompressedFileInfo-After"}}"),
-		},
+{{end}}
+
+
+
+{{define "CompressedFileInfo-Close"}}		},
 {{end}}
 
 
@@ -273,12 +757,31 @@ var {{.VariableName}} = func() http.FileSystem {
 {{define "FileInfo-Before"}}		{{quote .Path}}: &vfsgen<<<.VariableName>>>۰FileInfo{
 			name:    {{quote .Name}},
 			modTime: {{template "Time" .ModTime}},
-			content: []byte("{{end}}{{define "FileInfo-After"}}"),
+{{end}}{{define "FileInfo-After"}}		},
+{{end}}
+
+
+
+{{define "ChunkedFileInfo"}}		{{quote .Path}}: &vfsgen<<<.VariableName>>>۰ChunkedFileInfo{
+			name:             {{quote .Name}},
+			modTime:          {{template "Time" .ModTime}},
+			uncompressedSize: {{.UncompressedSize}},
+			chunks:           [][]byte{ {{range .ChunkVars}}{{.}}, {{end}} },
 		},
 {{end}}
 
 
 
+{{define "Blob-Before"}}
+var vfsgen<<<.VariableName>>>۰{{.VarName}} = []byte("{{end}}
+
+
+
+{{define "Blob-After"}}")
+{{end}}
+
+
+
 {{define "DirInfo"}}		{{quote .Path}}: &vfsgen<<<.VariableName>>>۰DirInfo{
 			name:    {{quote .Name}},
 			modTime: {{template "Time" .ModTime}},
@@ -292,7 +795,7 @@ var {{.VariableName}} = func() http.FileSystem {
 		fs[{{quote .}}].(os.FileInfo),{{end}}
 	}
 {{end}}{{end}}
-	return fs
+	return <<<if .NeedsIOFS>>>vfsgen<<<.VariableName>>>۰IOFS{fs: fs}<<<else>>>fs<<<end>>>
 }()
 {{end}}
 
@@ -323,6 +826,11 @@ func (fs vfsgen<<<.VariableName>>>۰FS) Open(path string) (http.File, error) {
 		return &vfsgen<<<.VariableName>>>۰File{
 			vfsgen<<<.VariableName>>>۰FileInfo: f,
 			Reader:          bytes.NewReader(f.content),
+		}, nil{{end}}{{if .HasChunkedFile}}
+	case *vfsgen<<<.VariableName>>>۰ChunkedFileInfo:
+		return &vfsgen<<<.VariableName>>>۰ChunkedFile{
+			vfsgen<<<.VariableName>>>۰ChunkedFileInfo: f,
+			chunkIdx:                  -1,
 		}, nil{{end}}
 	case *vfsgen<<<.VariableName>>>۰DirInfo:
 		return &vfsgen<<<.VariableName>>>۰Dir{
@@ -339,7 +847,9 @@ type vfsgen<<<.VariableName>>>۰CompressedFileInfo struct {
 	name              string
 	modTime           time.Time
 	compressedContent []byte
-	uncompressedSize  int64
+<<<if .NeedsBrotli>>>	brotliContent     []byte
+<<<end>>><<<if .NeedsZstd>>>	zstdContent       []byte
+<<<end>>>	uncompressedSize  int64
 }
 
 func (f *vfsgen<<<.VariableName>>>۰CompressedFileInfo) Readdir(count int) ([]os.FileInfo, error) {
@@ -350,6 +860,35 @@ func (f *vfsgen<<<.VariableName>>>۰CompressedFileInfo) Stat() (os.FileInfo, err
 func (f *vfsgen<<<.VariableName>>>۰CompressedFileInfo) GzipBytes() []byte {
 	return f.compressedContent
 }
+<<<if .NeedsBrotli>>>
+func (f *vfsgen<<<.VariableName>>>۰CompressedFileInfo) BrotliBytes() []byte {
+	return f.brotliContent
+}
+<<<end>>><<<if .NeedsZstd>>>
+func (f *vfsgen<<<.VariableName>>>۰CompressedFileInfo) ZstdBytes() []byte {
+	return f.zstdContent
+}
+<<<end>>>
+// EncodedBytes returns the precomputed bytes for encoding (e.g. "gzip",
+// "br", "zstd"), and whether that encoding is available for this file.
+func (f *vfsgen<<<.VariableName>>>۰CompressedFileInfo) EncodedBytes(encoding string) ([]byte, bool) {
+	switch encoding {
+	case "gzip":
+		return f.compressedContent, true
+<<<if .NeedsBrotli>>>	case "br":
+		if f.brotliContent == nil {
+			return nil, false
+		}
+		return f.brotliContent, true
+<<<end>>><<<if .NeedsZstd>>>	case "zstd":
+		if f.zstdContent == nil {
+			return nil, false
+		}
+		return f.zstdContent, true
+<<<end>>>	default:
+		return nil, false
+	}
+}
 
 func (f *vfsgen<<<.VariableName>>>۰CompressedFileInfo) Name() string       { return f.name }
 func (f *vfsgen<<<.VariableName>>>۰CompressedFileInfo) Size() int64        { return f.uncompressedSize }
@@ -439,9 +978,117 @@ type vfsgen<<<.VariableName>>>۰File struct {
 func (f *vfsgen<<<.VariableName>>>۰File) Close() error {
 	return nil
 }
-{{else if not .HasCompressedFile}}
+{{else if and (not .HasCompressedFile) (not .HasChunkedFile)}}
 // We already imported "bytes", but ended up not using it. Avoid unused import error.
 var _ = bytes.Reader{}
+{{end}}{{if .HasChunkedFile}}
+// vfsgen<<<.VariableName>>>۰ChunkedFileInfo is a static definition of a large
+// file stored as content-deduplicated, individually gzip-compressed chunks
+// (see Options.Dedupe and Options.ChunkSize).
+type vfsgen<<<.VariableName>>>۰ChunkedFileInfo struct {
+	name             string
+	modTime          time.Time
+	uncompressedSize int64
+	chunks           [][]byte // each gzip-compressed independently
+}
+
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFileInfo) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, fmt.Errorf("cannot Readdir from file %s", f.name)
+}
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFileInfo) Stat() (os.FileInfo, error) { return f, nil }
+
+// GzipBytes returns the file's content as the concatenation of its
+// independently gzip-compressed chunks. gzip.Reader (and any
+// multistream-aware gzip decoder, which includes every major browser)
+// decodes a concatenation of gzip members transparently as a single
+// stream, so this is valid precomputed gzip content for the whole file.
+// It's assembled on demand, rather than precomputed once, so that
+// byte-identical chunks shared with other files (see Options.Dedupe)
+// still aren't duplicated in the generated code.
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFileInfo) GzipBytes() []byte {
+	var buf bytes.Buffer
+	for _, chunk := range f.chunks {
+		buf.Write(chunk)
+	}
+	return buf.Bytes()
+}
+
+// EncodedBytes returns the precomputed bytes for encoding, and whether
+// it's available for this file. Chunked files are always gzip-compressed,
+// but (unlike CompressedFileInfo) never get the additional Encodings
+// (Brotli, Zstd): those don't have gzip's well-supported multistream
+// concatenation, so a per-chunk Brotli or Zstd stream can't be stitched
+// back together the same way.
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFileInfo) EncodedBytes(encoding string) ([]byte, bool) {
+	if encoding != "gzip" {
+		return nil, false
+	}
+	return f.GzipBytes(), true
+}
+
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFileInfo) Name() string       { return f.name }
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFileInfo) Size() int64        { return f.uncompressedSize }
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFileInfo) Mode() os.FileMode  { return 0444 }
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFileInfo) ModTime() time.Time { return f.modTime }
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFileInfo) IsDir() bool        { return false }
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFileInfo) Sys() interface{}   { return nil }
+
+// vfsgen<<<.VariableName>>>۰ChunkedFile is an opened chunked file instance.
+type vfsgen<<<.VariableName>>>۰ChunkedFile struct {
+	*vfsgen<<<.VariableName>>>۰ChunkedFileInfo
+	pos      int64  // Current read/seek position, in uncompressed bytes.
+	chunkIdx int    // Index of the chunk currently decoded into buf, or -1 if none yet.
+	buf      []byte // Decoded (uncompressed) content of chunk chunkIdx.
+}
+
+// chunkAt returns the decoded (uncompressed) content of the chunk at idx,
+// gunzipping it the first time it's needed and caching it until a
+// different chunk is requested.
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFile) chunkAt(idx int) ([]byte, error) {
+	if idx == f.chunkIdx {
+		return f.buf, nil
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(f.chunks[idx]))
+	if err != nil {
+		// This should never happen because we generate the gzip bytes such that they are always valid.
+		panic("unexpected error reading own gzip compressed chunk: " + err.Error())
+	}
+	buf, err := ioutil.ReadAll(gr)
+	if err != nil {
+		return nil, err
+	}
+	f.chunkIdx, f.buf = idx, buf
+	return buf, nil
+}
+
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFile) Read(p []byte) (n int, err error) {
+	if f.pos >= f.uncompressedSize {
+		return 0, io.EOF
+	}
+	chunk, err := f.chunkAt(int(f.pos / <<<.ChunkSize>>>))
+	if err != nil {
+		return 0, err
+	}
+	n = copy(p, chunk[f.pos%<<<.ChunkSize>>>:])
+	f.pos += int64(n)
+	return n, nil
+}
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		f.pos = 0 + offset
+	case io.SeekCurrent:
+		f.pos += offset
+	case io.SeekEnd:
+		f.pos = f.uncompressedSize + offset
+	default:
+		panic(fmt.Errorf("invalid whence value: %v", whence))
+	}
+	return f.pos, nil
+}
+func (f *vfsgen<<<.VariableName>>>۰ChunkedFile) Close() error {
+	return nil
+}
 {{end}}
 // vfsgen<<<.VariableName>>>۰DirInfo is a static definition of a directory.
 type vfsgen<<<.VariableName>>>۰DirInfo struct {
@@ -488,6 +1135,145 @@ func (d *vfsgen<<<.VariableName>>>۰Dir) Readdir(count int) ([]os.FileInfo, erro
 	d.pos += count
 	return e, nil
 }
+<<<if .NeedsIOFS>>>
+func (d *vfsgen<<<.VariableName>>>۰Dir) ReadDir(n int) ([]iofs.DirEntry, error) {
+	fis, err := d.Readdir(n)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]iofs.DirEntry, len(fis))
+	for i, fi := range fis {
+		entries[i] = vfsgen<<<.VariableName>>>۰DirEntry{FileInfo: fi}
+	}
+	return entries, nil
+}
+
+// vfsgen<<<.VariableName>>>۰DirEntry adapts an os.FileInfo to fs.DirEntry.
+type vfsgen<<<.VariableName>>>۰DirEntry struct {
+	os.FileInfo
+}
+
+func (d vfsgen<<<.VariableName>>>۰DirEntry) Type() iofs.FileMode          { return d.FileInfo.Mode().Type() }
+func (d vfsgen<<<.VariableName>>>۰DirEntry) Info() (iofs.FileInfo, error) { return d.FileInfo, nil }
+
+// vfsgen<<<.VariableName>>>۰IOFS adapts vfsgen<<<.VariableName>>>۰FS to the
+// io/fs.FS family of interfaces: fs.FS, fs.ReadFileFS, fs.ReadDirFS,
+// fs.StatFS, fs.SubFS and fs.GlobFS.
+type vfsgen<<<.VariableName>>>۰IOFS struct {
+	fs vfsgen<<<.VariableName>>>۰FS
+}
+
+// open validates name as required by io/fs, then opens the corresponding
+// entry of f.fs (which uses absolute, '/'-rooted paths).
+func (f vfsgen<<<.VariableName>>>۰IOFS) open(op, name string) (http.File, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+	return f.fs.Open("/" + name)
+}
+
+func (f vfsgen<<<.VariableName>>>۰IOFS) Open(name string) (iofs.File, error) {
+	return f.open("open", name)
+}
+
+func (f vfsgen<<<.VariableName>>>۰IOFS) ReadFile(name string) ([]byte, error) {
+	file, err := f.open("read", name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, &iofs.PathError{Op: "read", Path: name, Err: iofs.ErrInvalid}
+	}
+	return ioutil.ReadAll(file)
+}
+
+func (f vfsgen<<<.VariableName>>>۰IOFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	file, err := f.open("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	fis, err := file.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]iofs.DirEntry, len(fis))
+	for i, fi := range fis {
+		entries[i] = vfsgen<<<.VariableName>>>۰DirEntry{FileInfo: fi}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (f vfsgen<<<.VariableName>>>۰IOFS) Stat(name string) (iofs.FileInfo, error) {
+	file, err := f.open("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return file.Stat()
+}
+
+func (f vfsgen<<<.VariableName>>>۰IOFS) Sub(dir string) (iofs.FS, error) {
+	if dir == "." {
+		return f, nil
+	}
+	if _, err := f.open("sub", dir); err != nil {
+		return nil, err
+	}
+	full := "/" + dir
+	prefix := full + "/"
+	sub := make(vfsgen<<<.VariableName>>>۰FS, len(f.fs))
+	for name, v := range f.fs {
+		switch {
+		case name == full:
+			sub["/"] = v
+		case strings.HasPrefix(name, prefix):
+			sub["/"+strings.TrimPrefix(name, prefix)] = v
+		}
+	}
+	return vfsgen<<<.VariableName>>>۰IOFS{fs: sub}, nil
+}
+
+func (f vfsgen<<<.VariableName>>>۰IOFS) Glob(pattern string) ([]string, error) {
+	if _, err := pathpkg.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range f.fs {
+		rel := strings.TrimPrefix(name, "/")
+		if rel == "" {
+			continue
+		}
+		ok, err := pathpkg.Match(pattern, rel)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			names = append(names, rel)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// HTTP adapts f back to an http.FileSystem, for callers that still need the
+// pre-io/fs interface.
+func (f vfsgen<<<.VariableName>>>۰IOFS) HTTP() http.FileSystem {
+	return f.fs
+}
+<<<if .IsBoth>>>
+// <<<.VariableName>>>HTTP returns <<<.VariableName>>> adapted to
+// http.FileSystem, for callers that still need the pre-io/fs interface.
+func <<<.VariableName>>>HTTP() http.FileSystem {
+	return <<<.VariableName>>>.(vfsgen<<<.VariableName>>>۰IOFS).HTTP()
+}
+<<<end>>><<<end>>>
 {{end}}
 
 
@@ -0,0 +1,145 @@
+package vfsgen
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildAndRun writes vfsdataSrc and mainSrc into a throwaway, dependency-free
+// module and runs it with "go run", returning its stdout. Both sources must
+// only import the standard library: the throwaway module has no access to
+// this repo's own dependencies, module cache, or network.
+func buildAndRun(t *testing.T, vfsdataSrc, mainSrc string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":            "module gentest\n\ngo 1.21\n",
+		"assets_vfsdata.go": vfsdataSrc,
+		"main.go":           mainSrc,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOPROXY=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run generated code: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+// generateSource runs Generate against input, forcing opt.PackageName to
+// "main" and opt.Output to capture the result, and returns the generated
+// source as a string.
+func generateSource(t *testing.T, input http.FileSystem, opt Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	opt.Output = &buf
+	opt.PackageName = "main"
+	if err := Generate(input, opt); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	return buf.String()
+}
+
+// fixedModTime is used by tests that build a memTree, so generated ModTime
+// literals (and thus generated source) don't depend on wall-clock time.
+var fixedModTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestGenerateIOFS(t *testing.T) {
+	tree := newMemTree()
+	tree.addFile("/hello.txt", fixedModTime, 5, func() ([]byte, error) { return []byte("hello"), nil })
+	tree.addFile("/sub/world.txt", fixedModTime, 5, func() ([]byte, error) { return []byte("world"), nil })
+
+	src := generateSource(t, tree, Options{VariableName: "assets", FSInterface: IOFS})
+
+	const mainSrc = `package main
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+func main() {
+	b, err := fs.ReadFile(assets, "hello.txt")
+	fmt.Println(string(b), err)
+
+	entries, err := fs.ReadDir(assets, "sub")
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = fmt.Sprintf("%s dir=%v", e.Name(), e.IsDir())
+	}
+	sort.Strings(names)
+	fmt.Println(names, err)
+
+	fi, err := fs.Stat(assets, "hello.txt")
+	fmt.Println(fi.Name(), fi.Size(), err)
+
+	sub, err := fs.Sub(assets, "sub")
+	if err != nil {
+		fmt.Println("Sub error:", err)
+		return
+	}
+	b2, err := fs.ReadFile(sub, "world.txt")
+	fmt.Println(string(b2), err)
+
+	matches, err := fs.Glob(assets, "*.txt")
+	fmt.Println(matches, err)
+}
+`
+	got := buildAndRun(t, src, mainSrc)
+	want := "hello <nil>\n" +
+		"[world.txt dir=false] <nil>\n" +
+		"hello.txt 5 <nil>\n" +
+		"world <nil>\n" +
+		"[hello.txt] <nil>\n"
+	if got != want {
+		t.Errorf("output mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGenerateBoth(t *testing.T) {
+	tree := newMemTree()
+	tree.addFile("/index.html", fixedModTime, 4, func() ([]byte, error) { return []byte("<p>"), nil })
+
+	src := generateSource(t, tree, Options{VariableName: "assets", FSInterface: Both})
+
+	const mainSrc = `package main
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+func main() {
+	b, err := fs.ReadFile(assets, "index.html")
+	fmt.Println(string(b), err)
+
+	hfs := assetsHTTP()
+	f, err := hfs.Open("/index.html")
+	if err != nil {
+		fmt.Println("Open error:", err)
+		return
+	}
+	defer f.Close()
+	buf := make([]byte, 4)
+	n, err := f.Read(buf)
+	fmt.Println(string(buf[:n]), err)
+}
+`
+	got := buildAndRun(t, src, mainSrc)
+	want := "<p> <nil>\n<p> <nil>\n"
+	if got != want {
+		t.Errorf("output mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,209 @@
+package vfsgen
+
+import (
+	"fmt"
+	"io"
+	pathpkg "path"
+	"runtime"
+	"strings"
+)
+
+// FSInterface controls which filesystem interface(s) the code generated by
+// Generate implements.
+type FSInterface int
+
+const (
+	// HTTPFS generates a variable that implements http.FileSystem only.
+	// This is the default, and matches vfsgen's pre-Go-1.16 behavior.
+	HTTPFS FSInterface = iota
+
+	// IOFS generates a variable that implements io/fs.FS (and, where
+	// the generated file supports it, fs.ReadFileFS, fs.ReadDirFS,
+	// fs.StatFS, fs.SubFS and fs.GlobFS) instead of http.FileSystem.
+	IOFS
+
+	// Both generates a variable that implements io/fs.FS (per IOFS),
+	// plus a "<VariableName>HTTP()" helper function that adapts it to
+	// http.FileSystem for callers that still need the older interface.
+	Both
+)
+
+// Options for vfsgen.Generate.
+type Options struct {
+	// Filename of the generated Go code output (including extension).
+	// If left empty, it defaults to "{toLower(VariableName)}_vfsdata.go".
+	// Ignored if Output is set.
+	Filename string
+
+	// Output, if set, is written to directly instead of creating a file at
+	// Filename. This lets callers pipe generated code into another tool
+	// (e.g. gofmt) or into an in-memory build without touching disk.
+	Output io.Writer
+
+	// PackageName is the name of the package in the generated code.
+	// If left empty, it defaults to "main".
+	PackageName string
+
+	// BuildTags are the optional build tags in the generated code.
+	// The build tags syntax is specified in the go/build package:
+	// https://golang.org/pkg/go/build/#hdr-Build_Constraints.
+	BuildTags string
+
+	// VariableName is the name of the exported variable in the generated code.
+	// If left empty, it defaults to "assets".
+	VariableName string
+
+	// VariableComment is the comment of the exported variable in the generated code.
+	// If left empty, it defaults to "{VariableName} statically implements the virtual filesystem provided to vfsgen.".
+	VariableComment string
+
+	// FSInterface selects which filesystem interface(s) the generated
+	// variable implements. If left unset, it defaults to HTTPFS, matching
+	// vfsgen's historical behavior.
+	FSInterface FSInterface
+
+	// Encodings is the set of additional content encodings, beyond the
+	// gzip encoding Generate always attempts, to precompute and embed for
+	// each file. A given encoding is only embedded for a file if it beats
+	// the uncompressed size; it's otherwise silently dropped for that file.
+	Encodings []Encoding
+
+	// MinCompressRatio is the maximum compressed/uncompressed size ratio
+	// a file must achieve to be stored compressed; files that don't beat
+	// it are stored uncompressed instead. If left zero, it defaults to 0.8.
+	MinCompressRatio float64
+
+	// SkipCompressExtensions lists file extensions (e.g. ".png", ".woff2",
+	// ".gz") that are assumed to already be compressed, and so are never
+	// even attempted to be compressed. Matching is case-insensitive.
+	SkipCompressExtensions []string
+
+	// Verbose makes Generate log a summary line per file, noting whether
+	// and how well it compressed.
+	Verbose bool
+
+	// Dedupe selects whether and how Generate deduplicates identical
+	// content across the embedded asset tree. If left unset, it defaults
+	// to DedupeOff.
+	Dedupe Dedupe
+
+	// ChunkSize is the chunk size, in bytes, used to split files larger
+	// than ChunkSize when Dedupe is DedupeChunk. If left zero, it
+	// defaults to 64 KiB.
+	ChunkSize int64
+
+	// Concurrency is the number of files Generate compresses in parallel.
+	// If left zero, it defaults to runtime.GOMAXPROCS(0).
+	Concurrency int
+}
+
+// Dedupe controls whether Generate deduplicates identical content across
+// the embedded asset tree.
+type Dedupe int
+
+const (
+	// DedupeOff embeds every file's content independently. This is the
+	// default, and matches vfsgen's historical behavior.
+	DedupeOff Dedupe = iota
+
+	// DedupeFile deduplicates whole files: when two files (regardless of
+	// size) have byte-identical content, the content is embedded once and
+	// both reference the same generated blob variable.
+	DedupeFile
+
+	// DedupeChunk is like DedupeFile for files no larger than
+	// Options.ChunkSize, but additionally splits larger files into
+	// fixed-size chunks and deduplicates those individually, so large
+	// files that mostly overlap still share most of their storage. Each
+	// chunk is always gzip-compressed independently of the others,
+	// regardless of MinCompressRatio or SkipCompressExtensions; unlike
+	// whole-file compression, chunks never get the additional Encodings
+	// (Brotli, Zstd), since those don't support gzip's well-defined
+	// multistream concatenation.
+	DedupeChunk
+)
+
+// Encoding identifies an additional precomputed content encoding that
+// Generate can embed alongside a file's gzip encoding.
+type Encoding string
+
+const (
+	// Brotli precomputes a Brotli-compressed variant of each file, exposed
+	// via the generated CompressedFileInfo's BrotliBytes method.
+	Brotli Encoding = "br"
+
+	// Zstd precomputes a Zstandard-compressed variant of each file, exposed
+	// via the generated CompressedFileInfo's ZstdBytes method.
+	Zstd Encoding = "zstd"
+)
+
+// fillMissing sets default values for mandatory options that are left empty.
+func (opt *Options) fillMissing() {
+	if opt.PackageName == "" {
+		opt.PackageName = "main"
+	}
+	if opt.VariableName == "" {
+		opt.VariableName = "assets"
+	}
+	if opt.Filename == "" {
+		opt.Filename = fmt.Sprintf("%s_vfsdata.go", strings.ToLower(opt.VariableName))
+	}
+	if opt.VariableComment == "" {
+		opt.VariableComment = fmt.Sprintf("%s statically implements the virtual filesystem provided to vfsgen.", opt.VariableName)
+	}
+	if opt.MinCompressRatio == 0 {
+		opt.MinCompressRatio = 0.8
+	}
+	if opt.ChunkSize == 0 {
+		opt.ChunkSize = 64 * 1024
+	}
+	if opt.Concurrency == 0 {
+		opt.Concurrency = runtime.GOMAXPROCS(0)
+	}
+}
+
+// IsIOFS reports whether opt.FSInterface is IOFS.
+func (opt Options) IsIOFS() bool { return opt.FSInterface == IOFS }
+
+// IsBoth reports whether opt.FSInterface is Both.
+func (opt Options) IsBoth() bool { return opt.FSInterface == Both }
+
+// NeedsIOFS reports whether the generated code needs to import io/fs.
+func (opt Options) NeedsIOFS() bool { return opt.FSInterface == IOFS || opt.FSInterface == Both }
+
+// hasEncoding reports whether opt.Encodings contains enc.
+func (opt Options) hasEncoding(enc Encoding) bool {
+	for _, e := range opt.Encodings {
+		if e == enc {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsBrotli reports whether opt.Encodings opts into Brotli.
+func (opt Options) NeedsBrotli() bool { return opt.hasEncoding(Brotli) }
+
+// NeedsZstd reports whether opt.Encodings opts into Zstd.
+func (opt Options) NeedsZstd() bool { return opt.hasEncoding(Zstd) }
+
+// SkipsCompression reports whether path's extension is listed in
+// opt.SkipCompressExtensions, and so should never be compressed.
+func (opt Options) SkipsCompression(path string) bool {
+	ext := pathpkg.Ext(path)
+	for _, skip := range opt.SkipCompressExtensions {
+		if strings.EqualFold(skip, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsDedupe reports whether opt.Dedupe enables content deduplication.
+func (opt Options) NeedsDedupe() bool { return opt.Dedupe != DedupeOff }
+
+// chunked reports whether a file of the given uncompressed size should be
+// stored as deduplicated chunks rather than as a single embedded blob.
+func (opt Options) chunked(size int64) bool {
+	return opt.Dedupe == DedupeChunk && size > opt.ChunkSize
+}
@@ -0,0 +1,86 @@
+package vfsgen
+
+import (
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestSkipsCompression(t *testing.T) {
+	opt := Options{SkipCompressExtensions: []string{".png", ".WOFF2"}}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/img/logo.png", true},
+		{"/fonts/a.woff2", true}, // case-insensitive match against ".WOFF2"
+		{"/fonts/a.WOFF2", true},
+		{"/index.html", false},
+		{"/img/logo.PNG", true},
+	}
+	for _, tt := range tests {
+		if got := opt.SkipsCompression(tt.path); got != tt.want {
+			t.Errorf("SkipsCompression(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTryCompressSkipsExtension(t *testing.T) {
+	opt := Options{}
+	opt.fillMissing()
+	opt.SkipCompressExtensions = []string{".bin"}
+
+	content := compressible // long and repetitive, would otherwise compress well
+	file := &fileInfo{Path: "/asset.bin", UncompressedSize: int64(len(content))}
+
+	data, ok, err := tryCompress(content, file, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok || data != nil {
+		t.Fatalf("tryCompress = (%v, %v), want (nil, false) for a skipped extension", data, ok)
+	}
+}
+
+func TestTryCompressMinCompressRatio(t *testing.T) {
+	opt := Options{}
+	opt.fillMissing()
+
+	content := compressible
+	file := &fileInfo{Path: "/asset.txt", UncompressedSize: int64(len(content))}
+
+	// The default ratio (0.8) should accept this highly compressible content.
+	if _, ok, err := tryCompress(content, file, opt); err != nil || !ok {
+		t.Fatalf("tryCompress with default MinCompressRatio: ok=%v err=%v, want ok=true", ok, err)
+	}
+
+	// An unreasonably strict ratio should reject the same content.
+	opt.MinCompressRatio = 0.0001
+	if _, ok, err := tryCompress(content, file, opt); err != nil || ok {
+		t.Fatalf("tryCompress with strict MinCompressRatio: ok=%v err=%v, want ok=false", ok, err)
+	}
+}
+
+func TestVerboseLogging(t *testing.T) {
+	var buf strings.Builder
+	orig := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(orig)
+	defer log.SetFlags(log.Flags())
+
+	opt := Options{Verbose: true}
+	opt.fillMissing()
+
+	content := compressible
+	file := &fileInfo{Path: "/asset.txt", UncompressedSize: int64(len(content))}
+	if _, ok, err := tryCompress(content, file, opt); err != nil || !ok {
+		t.Fatalf("tryCompress: ok=%v err=%v", ok, err)
+	}
+
+	if !strings.Contains(buf.String(), "/asset.txt") {
+		t.Errorf("expected Verbose log to mention the file path, got: %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "gzip") {
+		t.Errorf("expected Verbose log to mention gzip, got: %q", buf.String())
+	}
+}
@@ -0,0 +1,223 @@
+// Package vfshttp provides an http.Handler for serving the virtual
+// filesystems vfsgen generates, with support for the precompressed
+// gzip/brotli/zstd content vfsgen can embed.
+package vfshttp
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Options controls the behavior of the handler returned by New.
+type Options struct {
+	// DisableIndexHTML disables serving a directory's index.html file in
+	// place of the directory itself. If set, requests for a directory
+	// result in a 404.
+	DisableIndexHTML bool
+}
+
+// preferredEncodings lists the content encodings New will offer, in the
+// order they're tried when the client's Accept-Encoding allows more than
+// one. Brotli and zstd generally compress better than gzip, so they're
+// preferred when available and accepted.
+var preferredEncodings = []string{"br", "zstd", "gzip"}
+
+// encodedByter is implemented by the CompressedFileInfo type vfsgen
+// generates; it exposes whichever precomputed content encodings were
+// embedded for a file.
+type encodedByter interface {
+	EncodedBytes(encoding string) ([]byte, bool)
+}
+
+// New returns an http.Handler that serves the files in fs. It detects
+// vfsgen's generated EncodedBytes method to serve precompressed content
+// directly (honoring the request's Accept-Encoding and its q-values),
+// sets strong ETags derived from each file's ModTime and size, and
+// supports the usual conditional-GET headers via http.ServeContent.
+func New(fs http.FileSystem, opt Options) http.Handler {
+	return &handler{fs: fs, opt: opt}
+}
+
+type handler struct {
+	fs  http.FileSystem
+	opt Options
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upath := r.URL.Path
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
+		r.URL.Path = upath
+	}
+	name := path.Clean(upath)
+
+	f, err := h.fs.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	d, err := f.Stat()
+	if err != nil {
+		f.Close()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if d.IsDir() {
+		if upath[len(upath)-1] != '/' {
+			f.Close()
+			localRedirect(w, r, path.Base(upath)+"/")
+			return
+		}
+		f.Close()
+		if h.opt.DisableIndexHTML {
+			http.NotFound(w, r)
+			return
+		}
+		name = path.Join(name, "index.html")
+		f, err = h.fs.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		d, err = f.Stat()
+		if err != nil {
+			f.Close()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else if upath[len(upath)-1] == '/' {
+		f.Close()
+		localRedirect(w, r, "../"+path.Base(upath))
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.Header().Set("ETag", etag(d.ModTime(), d.Size()))
+
+	if eb, ok := f.(encodedByter); ok {
+		if enc, data, ok := pickEncoding(r.Header.Get("Accept-Encoding"), eb); ok {
+			if err := setContentType(w, d.Name(), f); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Encoding", enc)
+			http.ServeContent(w, r, d.Name(), d.ModTime(), bytes.NewReader(data))
+			return
+		}
+	}
+
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, "file does not support seeking", http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, d.Name(), d.ModTime(), rs)
+}
+
+// etag returns a strong ETag derived from a file's modification time and
+// size. It isn't a content hash, but it changes whenever modTime or size
+// does, which is enough to validate the precomputed content vfsgen embeds.
+func etag(modTime interface{ UnixNano() int64 }, size int64) string {
+	return `"` + strconv.FormatInt(modTime.UnixNano(), 36) + "-" + strconv.FormatInt(size, 36) + `"`
+}
+
+// setContentType sets the response's Content-Type from name's extension,
+// or, failing that, by sniffing f's first 512 bytes. It exists because
+// ServeHTTP serves precompressed bytes directly via bytes.NewReader,
+// which would otherwise leave http.ServeContent to sniff the compressed
+// bytes themselves rather than the real content when name's extension
+// isn't in the mime table; f is rewound afterwards so the caller is free
+// to read it again (or, as here, not read it at all).
+func setContentType(w http.ResponseWriter, name string, f http.File) error {
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+		return nil
+	}
+	var buf [512]byte
+	n, err := io.ReadFull(f, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(buf[:n]))
+	return nil
+}
+
+// pickEncoding chooses the best content encoding available from eb that
+// acceptEncoding (the request's Accept-Encoding header) allows, preferring
+// encodings earlier in preferredEncodings. It reports ok=false if none of
+// the embedded encodings are acceptable, in which case the caller should
+// fall back to serving the file's uncompressed content.
+func pickEncoding(acceptEncoding string, eb encodedByter) (encoding string, data []byte, ok bool) {
+	if acceptEncoding == "" {
+		return "", nil, false
+	}
+	q := parseAcceptEncoding(acceptEncoding)
+	for _, enc := range preferredEncodings {
+		if !accepts(q, enc) {
+			continue
+		}
+		if data, ok := eb.EncodedBytes(enc); ok {
+			return enc, data, true
+		}
+	}
+	return "", nil, false
+}
+
+// parseAcceptEncoding parses an Accept-Encoding header into a map of
+// encoding name (or "*") to its q-value.
+func parseAcceptEncoding(header string) map[string]float64 {
+	q := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name := part
+		qvalue := 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			name = part[:i]
+			for _, param := range strings.Split(part[i+1:], ";") {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if f, err := strconv.ParseFloat(param[len("q="):], 64); err == nil {
+						qvalue = f
+					}
+				}
+			}
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = qvalue
+	}
+	return q
+}
+
+// accepts reports whether q (as returned by parseAcceptEncoding) allows enc,
+// falling back to the "*" wildcard entry when enc isn't listed explicitly.
+func accepts(q map[string]float64, enc string) bool {
+	if v, ok := q[enc]; ok {
+		return v > 0
+	}
+	if v, ok := q["*"]; ok {
+		return v > 0
+	}
+	return false
+}
+
+// localRedirect gives a Moved Permanently response, without converting
+// relative paths to absolute ones like http.Redirect does.
+func localRedirect(w http.ResponseWriter, r *http.Request, newPath string) {
+	if q := r.URL.RawQuery; q != "" {
+		newPath += "?" + q
+	}
+	w.Header().Set("Location", newPath)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
@@ -0,0 +1,193 @@
+package vfshttp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+// fakeFS is a minimal http.FileSystem test double that also implements
+// encodedByter, so tests can exercise New's precompressed-content
+// negotiation without generating real vfsgen output.
+type fakeFS map[string]*fakeFile
+
+func (fs fakeFS) Open(name string) (http.File, error) {
+	f, ok := fs[path.Clean(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return f.open(), nil
+}
+
+// fakeFile is a directory or file entry in a fakeFS.
+type fakeFile struct {
+	name    string
+	modTime time.Time
+	isDir   bool
+	content []byte
+	encoded map[string][]byte // encoding -> precomputed bytes, e.g. "gzip", "br"
+}
+
+// open returns a fresh handle onto f, so repeated Open calls (as happens
+// for the directory -> index.html fallback) don't share Read/Seek state.
+func (f *fakeFile) open() *openFakeFile {
+	return &openFakeFile{fakeFile: f, Reader: bytes.NewReader(f.content)}
+}
+
+type openFakeFile struct {
+	*fakeFile
+	*bytes.Reader
+}
+
+func (f *openFakeFile) Close() error               { return nil }
+func (f *openFakeFile) Stat() (os.FileInfo, error) { return fakeFileInfo{f.fakeFile}, nil }
+func (f *openFakeFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, nil
+}
+
+// EncodedBytes implements the encodedByter interface New detects.
+func (f *openFakeFile) EncodedBytes(encoding string) ([]byte, bool) {
+	d, ok := f.encoded[encoding]
+	return d, ok
+}
+
+type fakeFileInfo struct{ f *fakeFile }
+
+func (fi fakeFileInfo) Name() string { return fi.f.name }
+func (fi fakeFileInfo) Size() int64  { return int64(len(fi.f.content)) }
+func (fi fakeFileInfo) Mode() os.FileMode {
+	if fi.f.isDir {
+		return os.ModeDir | 0755
+	}
+	return 0444
+}
+func (fi fakeFileInfo) ModTime() time.Time { return fi.f.modTime }
+func (fi fakeFileInfo) IsDir() bool        { return fi.f.isDir }
+func (fi fakeFileInfo) Sys() interface{}   { return nil }
+
+var testModTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func doRequest(h http.Handler, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNegotiatesPreferredEncoding(t *testing.T) {
+	fs := fakeFS{
+		"/big.txt": {
+			name:    "big.txt",
+			modTime: testModTime,
+			content: []byte("plain content"),
+			encoded: map[string][]byte{
+				"gzip": []byte("gzip-bytes"),
+				"br":   []byte("br-bytes"),
+			},
+		},
+	}
+	h := New(fs, Options{})
+
+	rec := doRequest(h, "/big.txt", map[string]string{"Accept-Encoding": "gzip, br"})
+	if got, want := rec.Header().Get("Content-Encoding"), "br"; got != want {
+		t.Errorf("Content-Encoding = %q, want %q (br should be preferred over gzip)", got, want)
+	}
+	if got, want := rec.Body.String(), "br-bytes"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+
+	rec = doRequest(h, "/big.txt", map[string]string{"Accept-Encoding": "gzip"})
+	if got, want := rec.Header().Get("Content-Encoding"), "gzip"; got != want {
+		t.Errorf("Content-Encoding = %q, want %q", got, want)
+	}
+	if got, want := rec.Body.String(), "gzip-bytes"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestFallsBackToUncompressed(t *testing.T) {
+	fs := fakeFS{
+		"/big.txt": {
+			name:    "big.txt",
+			modTime: testModTime,
+			content: []byte("plain content"),
+			encoded: map[string][]byte{"gzip": []byte("gzip-bytes")},
+		},
+	}
+	h := New(fs, Options{})
+
+	for _, acceptEncoding := range []string{"", "deflate"} {
+		rec := doRequest(h, "/big.txt", map[string]string{"Accept-Encoding": acceptEncoding})
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("Accept-Encoding=%q: Content-Encoding = %q, want empty", acceptEncoding, got)
+		}
+		if got, want := rec.Body.String(), "plain content"; got != want {
+			t.Errorf("Accept-Encoding=%q: body = %q, want %q", acceptEncoding, got, want)
+		}
+	}
+}
+
+func TestETagConditionalGet(t *testing.T) {
+	fs := fakeFS{
+		"/plain.txt": {name: "plain.txt", modTime: testModTime, content: []byte("hello")},
+	}
+	h := New(fs, Options{})
+
+	rec := doRequest(h, "/plain.txt", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want 200", rec.Code)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("first request: ETag header not set")
+	}
+
+	rec = doRequest(h, "/plain.txt", map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional request: status = %d, want 304", rec.Code)
+	}
+}
+
+func TestDirectoryIndexRedirectAndServe(t *testing.T) {
+	fs := fakeFS{
+		"/sub":            {name: "sub", modTime: testModTime, isDir: true},
+		"/sub/index.html": {name: "index.html", modTime: testModTime, content: []byte("<p>index</p>")},
+	}
+	h := New(fs, Options{})
+
+	rec := doRequest(h, "/sub", nil)
+	if rec.Code != http.StatusMovedPermanently {
+		t.Fatalf("no trailing slash: status = %d, want 301", rec.Code)
+	}
+	if got, want := rec.Header().Get("Location"), "sub/"; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+
+	rec = doRequest(h, "/sub/", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("trailing slash: status = %d, want 200", rec.Code)
+	}
+	if got, want := rec.Body.String(), "<p>index</p>"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestDisableIndexHTML(t *testing.T) {
+	fs := fakeFS{
+		"/sub":            {name: "sub", modTime: testModTime, isDir: true},
+		"/sub/index.html": {name: "index.html", modTime: testModTime, content: []byte("<p>index</p>")},
+	}
+	h := New(fs, Options{DisableIndexHTML: true})
+
+	rec := doRequest(h, "/sub/", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
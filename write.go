@@ -0,0 +1,43 @@
+package vfsgen
+
+import (
+	"io"
+)
+
+// stringWriter is an io.Writer that writes a quoted string for the bytes
+// it's given, suitable for embedding directly inside a Go double-quoted
+// string literal. It keeps track of the number of bytes written via N, so
+// callers can compare compressed vs. uncompressed sizes.
+type stringWriter struct {
+	io.Writer
+	N int64
+}
+
+func (sw *stringWriter) Write(p []byte) (n int, err error) {
+	const lowerhex = "0123456789abcdef"
+	buf := make([]byte, 0, 4*len(p))
+	for _, b := range p {
+		switch {
+		case b == '\\':
+			buf = append(buf, `\\`...)
+		case b == '"':
+			buf = append(buf, `\"`...)
+		case b == '\n':
+			buf = append(buf, `\n`...)
+		case b == '\r':
+			buf = append(buf, `\r`...)
+		case b == '\t':
+			buf = append(buf, `\t`...)
+		case b >= 0x20 && b < 0x7f:
+			buf = append(buf, b)
+		default:
+			buf = append(buf, '\\', 'x', lowerhex[b>>4], lowerhex[b&0xF])
+		}
+	}
+	_, err = sw.Writer.Write(buf)
+	if err != nil {
+		return 0, err
+	}
+	sw.N += int64(len(p))
+	return len(p), nil
+}